@@ -0,0 +1,69 @@
+// Copyright 2020 VMware, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/vmware/go-ipfix/pkg/entities"
+	"github.com/vmware/go-ipfix/pkg/registry"
+)
+
+// defaultMaxBufferSize is used when CollectorInput.MaxBufferSize is left unset.
+const defaultMaxBufferSize uint16 = 65535
+
+// CollectorInput groups the arguments to InitCollectingProcess.
+type CollectorInput struct {
+	Address        net.Addr
+	MaxBufferSize  uint16
+	TemplateTTL    uint32
+	IANARegistry   registry.Registry
+	AntreaRegistry registry.Registry
+	// Registry, if non-nil, is the prometheus.Registerer the collector's metrics are registered
+	// on. If nil, the collectingProcess collects no metrics.
+	Registry prometheus.Registerer
+}
+
+// InitCollectingProcess creates a collectingProcess ready to decode messages received on
+// input.Address.
+func InitCollectingProcess(input CollectorInput) (*collectingProcess, error) {
+	if input.Address == nil {
+		return nil, fmt.Errorf("cannot create collectingProcess without a listen address")
+	} else if input.IANARegistry == nil || input.AntreaRegistry == nil {
+		return nil, fmt.Errorf("cannot create collectingProcess without IANA and Antrea registries")
+	}
+	maxBufferSize := input.MaxBufferSize
+	if maxBufferSize == 0 {
+		maxBufferSize = defaultMaxBufferSize
+	}
+	return &collectingProcess{
+		make(map[uint32]map[uint16][]*entities.InfoElement),
+		&sync.RWMutex{},
+		make(map[uint32]map[uint16]*OptionsTemplate),
+		&sync.RWMutex{},
+		input.TemplateTTL,
+		input.IANARegistry,
+		input.AntreaRegistry,
+		input.Address,
+		maxBufferSize,
+		make(chan bool),
+		make([]*entities.Message, 0),
+		newCollectorMetrics(input.Registry),
+	}, nil
+}
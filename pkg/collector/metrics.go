@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "ipfix"
+	metricsSubsystem = "collector"
+)
+
+// collectorMetrics holds the Prometheus collectors exported by a collectingProcess. It is nil
+// when the process was created without a Registerer, in which case all instrumentation is
+// skipped.
+type collectorMetrics struct {
+	messagesDecodedTotal  prometheus.Counter
+	recordsDecodedTotal   prometheus.Counter
+	templatesAddedTotal   prometheus.Counter
+	templatesExpiredTotal prometheus.Counter
+	// decodeErrorsTotal is labeled by observation domain ID so a misbehaving exporter can be
+	// identified without scraping the collector's logs.
+	decodeErrorsTotal *prometheus.CounterVec
+	// templatesPerObsDomain reports the number of live templates, labeled by observation domain ID.
+	templatesPerObsDomain *prometheus.GaugeVec
+	// decodeLatency is a Summary, not a Histogram, so that streaming p50/p90/p99 quantiles are
+	// tracked (via the beorn7/perks/quantile algorithm client_golang uses internally) without
+	// retaining a full latency distribution in memory.
+	decodeLatency prometheus.Summary
+}
+
+// newCollectorMetrics creates and registers the metrics for a collectingProcess on registerer.
+// It returns nil, disabling instrumentation, if registerer is nil.
+func newCollectorMetrics(registerer prometheus.Registerer) *collectorMetrics {
+	if registerer == nil {
+		return nil
+	}
+	m := &collectorMetrics{
+		messagesDecodedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "messages_decoded_total",
+			Help:      "Number of IPFIX messages decoded.",
+		}),
+		recordsDecodedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "records_decoded_total",
+			Help:      "Number of template and data records decoded.",
+		}),
+		templatesAddedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "templates_added_total",
+			Help:      "Number of templates added to templatesMap.",
+		}),
+		templatesExpiredTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "templates_expired_total",
+			Help:      "Number of templates removed from templatesMap on TTL expiration.",
+		}),
+		decodeErrorsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "decode_errors_total",
+			Help:      "Number of message decode errors, by observation domain ID.",
+		}, []string{"obs_domain_id"}),
+		templatesPerObsDomain: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "templates_per_obs_domain",
+			Help:      "Current number of live templates, by observation domain ID.",
+		}, []string{"obs_domain_id"}),
+		decodeLatency: prometheus.NewSummary(prometheus.SummaryOpts{
+			Namespace:  metricsNamespace,
+			Subsystem:  metricsSubsystem,
+			Name:       "message_decode_latency_seconds",
+			Help:       "Latency of decoding a single IPFIX message.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		}),
+	}
+	registerer.MustRegister(
+		m.messagesDecodedTotal,
+		m.recordsDecodedTotal,
+		m.templatesAddedTotal,
+		m.templatesExpiredTotal,
+		m.decodeErrorsTotal,
+		m.templatesPerObsDomain,
+		m.decodeLatency,
+	)
+	return m
+}
+
+func (m *collectorMetrics) recordDecodeError(obsDomainID uint32) {
+	if m == nil {
+		return
+	}
+	m.decodeErrorsTotal.WithLabelValues(obsDomainIDLabel(obsDomainID)).Inc()
+}
+
+func obsDomainIDLabel(obsDomainID uint32) string {
+	return strconv.FormatUint(uint64(obsDomainID), 10)
+}
@@ -0,0 +1,30 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestNewCollectorMetricsNilRegisterer(t *testing.T) {
+	if m := newCollectorMetrics(nil); m != nil {
+		t.Errorf("expected nil metrics when registerer is nil, got %v", m)
+	}
+}
+
+func TestNewCollectorMetricsRegisters(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := newCollectorMetrics(reg)
+	if m == nil {
+		t.Fatal("expected non-nil metrics when a registerer is supplied")
+	}
+	m.recordDecodeError(1)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	if len(families) == 0 {
+		t.Errorf("expected at least one metric family to be registered")
+	}
+}
@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"github.com/vmware/go-ipfix/pkg/entities"
+)
+
+// ProcessStatsOptions is the decoded content of an Exporting Process Reliability Statistics
+// options record (RFC 5477 §2.1): counts of messages and flow records the exporting process has
+// sent, scoped to an observation domain.
+type ProcessStatsOptions struct {
+	ObservationDomainID          uint32
+	ExportedMessageTotalCount    uint64
+	ExportedFlowRecordTotalCount uint64
+}
+
+// ReliabilityStatsOptions is the decoded content of a Metering Process Reliability Statistics
+// options record (RFC 5477 §2.2): counts of packets the metering process observed but could not
+// account for in an exported flow, e.g. due to resource exhaustion.
+type ReliabilityStatsOptions struct {
+	ObservationDomainID     uint32
+	IgnoredPacketTotalCount uint64
+	IgnoredOctetTotalCount  uint64
+}
+
+// SamplingOptions is the decoded content of a Selector/Sampling-Algorithm options record (RFC
+// 5477 §2.3): the sampling algorithm and rate in effect for an observation domain, needed to
+// correlate reported flow volume against the true, unsampled volume.
+type SamplingOptions struct {
+	ObservationDomainID    uint32
+	SelectorAlgorithm      uint16
+	SamplingPacketInterval uint32
+	SamplingPacketSpace    uint32
+}
+
+// DecodeOptionsRecord inspects which information elements are present on an options data record
+// and, if it recognizes the shape as one of the common options records the IPFIX registry
+// defines, returns the corresponding typed struct (ProcessStatsOptions, ReliabilityStatsOptions,
+// or SamplingOptions). It returns nil if the record does not match any recognized shape; callers
+// can still access it as a generic entities.Record in that case.
+func DecodeOptionsRecord(record entities.Record) interface{} {
+	if opts, ok := decodeProcessStatsOptions(record); ok {
+		return opts
+	}
+	if opts, ok := decodeReliabilityStatsOptions(record); ok {
+		return opts
+	}
+	if opts, ok := decodeSamplingOptions(record); ok {
+		return opts
+	}
+	return nil
+}
+
+func decodeProcessStatsOptions(record entities.Record) (*ProcessStatsOptions, bool) {
+	obsDomainID, ok := uint32Field(record, "observationDomainId")
+	if !ok {
+		return nil, false
+	}
+	messages, ok := uint64Field(record, "exportedMessageTotalCount")
+	if !ok {
+		return nil, false
+	}
+	flowRecords, ok := uint64Field(record, "exportedFlowRecordTotalCount")
+	if !ok {
+		return nil, false
+	}
+	return &ProcessStatsOptions{
+		ObservationDomainID:          obsDomainID,
+		ExportedMessageTotalCount:    messages,
+		ExportedFlowRecordTotalCount: flowRecords,
+	}, true
+}
+
+func decodeReliabilityStatsOptions(record entities.Record) (*ReliabilityStatsOptions, bool) {
+	obsDomainID, ok := uint32Field(record, "observationDomainId")
+	if !ok {
+		return nil, false
+	}
+	ignoredPackets, ok := uint64Field(record, "ignoredPacketTotalCount")
+	if !ok {
+		return nil, false
+	}
+	ignoredOctets, ok := uint64Field(record, "ignoredOctetTotalCount")
+	if !ok {
+		return nil, false
+	}
+	return &ReliabilityStatsOptions{
+		ObservationDomainID:     obsDomainID,
+		IgnoredPacketTotalCount: ignoredPackets,
+		IgnoredOctetTotalCount:  ignoredOctets,
+	}, true
+}
+
+func decodeSamplingOptions(record entities.Record) (*SamplingOptions, bool) {
+	obsDomainID, ok := uint32Field(record, "observationDomainId")
+	if !ok {
+		return nil, false
+	}
+	algorithm, ok := uint16Field(record, "selectorAlgorithm")
+	if !ok {
+		return nil, false
+	}
+	interval, hasInterval := uint32Field(record, "samplingPacketInterval")
+	space, hasSpace := uint32Field(record, "samplingPacketSpace")
+	if !hasInterval && !hasSpace {
+		return nil, false
+	}
+	return &SamplingOptions{
+		ObservationDomainID:    obsDomainID,
+		SelectorAlgorithm:      algorithm,
+		SamplingPacketInterval: interval,
+		SamplingPacketSpace:    space,
+	}, true
+}
+
+func uint16Field(record entities.Record, name string) (uint16, bool) {
+	element, exist := record.GetInfoElementWithValue(name)
+	if !exist {
+		return 0, false
+	}
+	value, ok := element.Value.(uint16)
+	return value, ok
+}
+
+func uint32Field(record entities.Record, name string) (uint32, bool) {
+	element, exist := record.GetInfoElementWithValue(name)
+	if !exist {
+		return 0, false
+	}
+	value, ok := element.Value.(uint32)
+	return value, ok
+}
+
+func uint64Field(record entities.Record, name string) (uint64, bool) {
+	element, exist := record.GetInfoElementWithValue(name)
+	if !exist {
+		return 0, false
+	}
+	value, ok := element.Value.(uint64)
+	return value, ok
+}
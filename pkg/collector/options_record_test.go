@@ -0,0 +1,39 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/vmware/go-ipfix/pkg/entities"
+)
+
+func newTestOptionsRecord(fields map[string]interface{}) entities.Record {
+	record := entities.NewDataRecord(0)
+	for name, value := range fields {
+		record.AddInfoElement(&entities.InfoElement{Name: name}, value)
+	}
+	return record
+}
+
+func TestDecodeOptionsRecordProcessStats(t *testing.T) {
+	record := newTestOptionsRecord(map[string]interface{}{
+		"observationDomainId":          uint32(1),
+		"exportedMessageTotalCount":    uint64(100),
+		"exportedFlowRecordTotalCount": uint64(200),
+	})
+	opts, ok := DecodeOptionsRecord(record).(*ProcessStatsOptions)
+	if !ok {
+		t.Fatalf("expected *ProcessStatsOptions, got %T", DecodeOptionsRecord(record))
+	}
+	if opts.ObservationDomainID != 1 || opts.ExportedMessageTotalCount != 100 || opts.ExportedFlowRecordTotalCount != 200 {
+		t.Errorf("unexpected decoded values: %+v", opts)
+	}
+}
+
+func TestDecodeOptionsRecordUnrecognized(t *testing.T) {
+	record := newTestOptionsRecord(map[string]interface{}{
+		"observationDomainId": uint32(1),
+	})
+	if opts := DecodeOptionsRecord(record); opts != nil {
+		t.Errorf("expected nil for an unrecognized options record shape, got %v", opts)
+	}
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/vmware/go-ipfix/pkg/entities"
+)
+
+func TestOptionsTemplateAllFields(t *testing.T) {
+	scope := &entities.InfoElement{Name: "observationDomainId"}
+	element := &entities.InfoElement{Name: "exportedMessageTotalCount"}
+	template := &OptionsTemplate{
+		TemplateID:      256,
+		ScopeFieldCount: 1,
+		Scopes:          []*entities.InfoElement{scope},
+		Elements:        []*entities.InfoElement{element},
+	}
+	fields := template.allFields()
+	if len(fields) != 2 || fields[0] != scope || fields[1] != element {
+		t.Errorf("expected allFields to return [scope, element] in wire order, got %v", fields)
+	}
+}
+
+func TestAddAndGetOptionsTemplate(t *testing.T) {
+	cp := &collectingProcess{
+		optionsTemplatesMap:  make(map[uint32]map[uint16]*OptionsTemplate),
+		optionsTemplatesLock: &sync.RWMutex{},
+	}
+	template := &OptionsTemplate{TemplateID: 256, ScopeFieldCount: 1}
+	cp.addOptionsTemplate(1, 256, template)
+
+	got, err := cp.GetOptionsTemplate(1, 256)
+	if err != nil {
+		t.Fatalf("GetOptionsTemplate failed: %v", err)
+	}
+	if got != template {
+		t.Errorf("expected to get back the template that was added")
+	}
+
+	if _, err := cp.GetOptionsTemplate(1, 257); err == nil {
+		t.Errorf("expected an error for an unknown templateID")
+	}
+}
@@ -35,6 +35,10 @@ type collectingProcess struct {
 	templatesMap map[uint32]map[uint16][]*entities.InfoElement
 	// templatesLock allows multiple readers or one writer at the same time
 	templatesLock *sync.RWMutex
+	// for each obsDomainID, there is a map of Options Templates (RFC 7011 set id 3), keyed by templateID
+	optionsTemplatesMap map[uint32]map[uint16]*OptionsTemplate
+	// optionsTemplatesLock allows multiple readers or one writer at the same time
+	optionsTemplatesLock *sync.RWMutex
 	// template lifetime
 	templateTTL uint32
 	// registries for decoding Information Element
@@ -48,32 +52,52 @@ type collectingProcess struct {
 	stopChan chan bool
 	// packet list
 	messages []*entities.Message
+	// metrics holds the Prometheus collectors for this process, populated from
+	// CollectorInput.Registry; nil if no Registerer was supplied
+	metrics *collectorMetrics
 }
 
 func (cp *collectingProcess) decodePacket(packetBuffer *bytes.Buffer) (*entities.Message, error) {
+	start := time.Now()
 	message := entities.Message{}
 	var id, length uint16
 	err := decode(packetBuffer, &message.Version, &message.BufferLength, &message.ExportTime, &message.SeqNumber, &message.ObsDomainID, &id, &length)
 	if err != nil {
+		cp.metrics.recordDecodeError(message.ObsDomainID)
 		return nil, fmt.Errorf("Error in decoding message: %v", err)
 	}
 	if message.Version != uint16(10) {
+		cp.metrics.recordDecodeError(message.ObsDomainID)
 		return nil, fmt.Errorf("Collector only supports IPFIX (v10). Invalid version %d received.", message.Version)
 	}
 	if id == 2 {
 		record, err := cp.decodeTemplateRecord(packetBuffer, message.ObsDomainID)
 		if err != nil {
+			cp.metrics.recordDecodeError(message.ObsDomainID)
+			return nil, fmt.Errorf("Error in decoding message: %v", err)
+		}
+		message.Record = record
+	} else if id == 3 {
+		record, err := cp.decodeOptionsTemplateRecord(packetBuffer, message.ObsDomainID)
+		if err != nil {
+			cp.metrics.recordDecodeError(message.ObsDomainID)
 			return nil, fmt.Errorf("Error in decoding message: %v", err)
 		}
 		message.Record = record
 	} else {
 		record, err := cp.decodeDataRecord(packetBuffer, message.ObsDomainID, id)
 		if err != nil {
+			cp.metrics.recordDecodeError(message.ObsDomainID)
 			return nil, fmt.Errorf("Error in decoding message: %v", err)
 		}
 		message.Record = record
 	}
 	cp.messages = append(cp.messages, &message)
+	if cp.metrics != nil {
+		cp.metrics.messagesDecodedTotal.Inc()
+		cp.metrics.recordsDecodedTotal.Inc()
+		cp.metrics.decodeLatency.Observe(time.Since(start).Seconds())
+	}
 	return &message, nil
 }
 
@@ -124,10 +148,14 @@ func (cp *collectingProcess) decodeTemplateRecord(templateBuffer *bytes.Buffer,
 }
 
 func (cp *collectingProcess) decodeDataRecord(dataBuffer *bytes.Buffer, obsDomainID uint32, templateID uint16) (*entities.DataRecord, error) {
-	// make sure template exists
+	// make sure template exists; the set id may reference either a regular or an Options Template
 	template, err := cp.getTemplate(obsDomainID, templateID)
 	if err != nil {
-		return nil, fmt.Errorf("Template %d with obsDomainID %d does not exist", templateID, obsDomainID)
+		optionsFields, exists := cp.getOptionsTemplateFields(obsDomainID, templateID)
+		if !exists {
+			return nil, fmt.Errorf("Template %d with obsDomainID %d does not exist", templateID, obsDomainID)
+		}
+		template = optionsFields
 	}
 	record := entities.NewDataRecord(templateID)
 	for _, field := range template {
@@ -145,7 +173,12 @@ func (cp *collectingProcess) addTemplate(obsDomainID uint32, templateID uint16,
 		cp.templatesMap[obsDomainID] = make(map[uint16][]*entities.InfoElement)
 	}
 	cp.templatesMap[obsDomainID][templateID] = elements
+	templateCount := len(cp.templatesMap[obsDomainID])
 	cp.templatesLock.Unlock()
+	if cp.metrics != nil {
+		cp.metrics.templatesAddedTotal.Inc()
+		cp.metrics.templatesPerObsDomain.WithLabelValues(obsDomainIDLabel(obsDomainID)).Set(float64(templateCount))
+	}
 	// template lifetime management
 	if cp.address.Network() == "tcp" {
 		return
@@ -179,8 +212,13 @@ func (cp *collectingProcess) getTemplate(obsDomainID uint32, templateID uint16)
 
 func (cp *collectingProcess) deleteTemplate(obsDomainID uint32, templateID uint16) {
 	cp.templatesLock.Lock()
-	defer cp.templatesLock.Unlock()
 	delete(cp.templatesMap[obsDomainID], templateID)
+	templateCount := len(cp.templatesMap[obsDomainID])
+	cp.templatesLock.Unlock()
+	if cp.metrics != nil {
+		cp.metrics.templatesExpiredTotal.Inc()
+		cp.metrics.templatesPerObsDomain.WithLabelValues(obsDomainIDLabel(obsDomainID)).Set(float64(templateCount))
+	}
 }
 
 func decode(buffer io.Reader, output ...interface{}) error {
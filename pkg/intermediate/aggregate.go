@@ -1,20 +1,28 @@
 package intermediate
 
 import (
+	"container/list"
 	"fmt"
 	"net"
-	"strings"
 	"sync"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/klog"
 
 	"github.com/vmware/go-ipfix/pkg/entities"
+	"github.com/vmware/go-ipfix/pkg/intermediate/filter"
 	"github.com/vmware/go-ipfix/pkg/registry"
 )
 
+// expiryScanInterval is how often the background goroutine scans flowKeyRecordMap for
+// idle/active expired flows. It is independent of, and typically much finer-grained than,
+// idleTimeout/activeTimeout themselves.
+const expiryScanInterval = 1 * time.Second
+
 type AggregationProcess struct {
-	// flowKeyRecordMap maps each connection (5-tuple) with its records
-	flowKeyRecordMap map[FlowKey][]entities.Record
+	// flowKeyRecordMap maps each flow key (as produced by flowKeyFunc) to its records and timers
+	flowKeyRecordMap map[FlowKey]*flowContext
 	// flowKeyRecordLock allows multiple readers or one writer at the same time
 	flowKeyRecordLock sync.RWMutex
 	// messageChan is the channel to receive the message
@@ -25,35 +33,144 @@ type AggregationProcess struct {
 	workerList []*worker
 	// correlateFields are the fields to be filled in correlating process
 	correlateFields []string
+	// flowKeyFunc extracts the FlowKey used to group records in flowKeyRecordMap
+	flowKeyFunc FlowKeyFunc
+	// idleTimeout is the duration of inactivity after which a flow is expired; zero disables it
+	idleTimeout time.Duration
+	// activeTimeout is the maximum duration a flow is kept regardless of activity; zero disables it
+	activeTimeout time.Duration
+	// maxFlows bounds the number of flows kept in flowKeyRecordMap, evicting the least-recently-updated
+	// flow once the bound is reached; zero means unbounded
+	maxFlows int
+	// lruList orders flows from most- to least-recently-updated; only populated when maxFlows > 0
+	lruList *list.List
+	// onFlowExpired is invoked, if non-nil, right before a flow's records are deleted from
+	// flowKeyRecordMap, whether due to idle/active timeout or LRU eviction
+	onFlowExpired FlowExpiredCallback
+	// metrics holds the Prometheus collectors for this process; nil if AggregationInput.Registry was nil
+	metrics *aggregationMetrics
+	// filterEngine, if non-nil, classifies and filters records in AggregateMsgByFlowKey before
+	// they are merged into flowKeyRecordMap
+	filterEngine *filter.Engine
 	// stopChan is the channel to receive stop message
 	stopChan chan bool
+	// expiryStopChan is the channel used to stop the expiry-scanning goroutine
+	expiryStopChan chan bool
+}
+
+// flowContext holds the records and activity timers tracked for a single FlowKey.
+type flowContext struct {
+	records []entities.Record
+	// firstSeen is the earliest flowStartSeconds among the flow's records, with wall clock time
+	// standing in for a record that lacks the IE
+	firstSeen time.Time
+	// lastSeen is the latest flowEndSeconds among the flow's records, with wall clock time
+	// standing in for a record that lacks the IE
+	lastSeen time.Time
+	// lruElement is this flow's element in lruList; nil unless maxFlows > 0
+	lruElement *list.Element
 }
 
-type FlowKey struct {
-	SourceAddress      string
-	DestinationAddress string
-	Protocol           uint8
-	SourcePort         uint16
-	DestinationPort    uint16
+// ExpiryReason identifies why a flow was removed from flowKeyRecordMap.
+type ExpiryReason int
+
+const (
+	// IdleTimeoutExpiry means the flow had no activity for longer than idleTimeout.
+	IdleTimeoutExpiry ExpiryReason = iota
+	// ActiveTimeoutExpiry means the flow has existed for longer than activeTimeout, regardless of activity.
+	ActiveTimeoutExpiry
+	// LRUEvictionExpiry means the flow was evicted to keep flowKeyRecordMap within maxFlows.
+	LRUEvictionExpiry
+)
+
+func (r ExpiryReason) String() string {
+	switch r {
+	case IdleTimeoutExpiry:
+		return "IdleTimeout"
+	case ActiveTimeoutExpiry:
+		return "ActiveTimeout"
+	case LRUEvictionExpiry:
+		return "LRUEviction"
+	default:
+		return "Unknown"
+	}
 }
 
+// FlowExpiredCallback is invoked when a flow is removed from flowKeyRecordMap by the idle/active
+// timers or by LRU eviction, before its records are deleted.
+type FlowExpiredCallback func(flowKey FlowKey, records []entities.Record, reason ExpiryReason)
+
+// FlowKey is an opaque, comparable identifier for the flow a record belongs to. It has no
+// meaning beyond map lookups in flowKeyRecordMap; callers should treat it as a black box and
+// only obtain values through a FlowKeyFunc.
+type FlowKey string
+
+// FlowKeyFunc derives the FlowKey used to group a record into flowKeyRecordMap. Built-in
+// extractors are provided below (FiveTupleFlowKey, FiveTupleVLANDSCPFlowKey,
+// IngressInterfaceSourcePrefixFlowKey, SubscriberFlowKey); callers may also supply their own to
+// aggregate at arbitrary granularities (e.g. per-tenant, per-application).
+type FlowKeyFunc func(record entities.Record) (FlowKey, error)
+
 type FlowKeyRecordMapCallBack func(key FlowKey, records []entities.Record) error
 
-// InitAggregationProcess takes in message channel (e.g. from collector) as input channel, workerNum(number of workers to process message)
-// and correlateFields (fields to be correlated and filled).
-func InitAggregationProcess(messageChan chan *entities.Message, workerNum int, correlateFields []string) (*AggregationProcess, error) {
-	if messageChan == nil {
+// AggregationInput provides the options for creating a new AggregationProcess.
+type AggregationInput struct {
+	// MessageChan is used to receive incoming messages, e.g. from a collector.
+	MessageChan chan *entities.Message
+	// WorkerNum is the number of workers to process incoming messages.
+	WorkerNum int
+	// CorrelateFields are the fields to be correlated and filled in the correlating process.
+	CorrelateFields []string
+	// FlowKeyFunc derives the aggregation key for each record. Defaults to FiveTupleFlowKey.
+	FlowKeyFunc FlowKeyFunc
+	// IdleTimeout is the duration of inactivity after which a flow is expired. Zero disables idle expiration.
+	IdleTimeout time.Duration
+	// ActiveTimeout is the maximum duration a flow is kept regardless of activity. Zero disables active expiration.
+	ActiveTimeout time.Duration
+	// MaxFlows bounds the number of flows kept in flowKeyRecordMap; the least-recently-updated
+	// flow is evicted once the bound is reached. Zero means unbounded.
+	MaxFlows int
+	// OnFlowExpired, if non-nil, is invoked right before an expired flow's records are deleted
+	// from flowKeyRecordMap, whether due to idle/active timeout or LRU eviction.
+	OnFlowExpired FlowExpiredCallback
+	// Registry, if non-nil, is used to register this process's Prometheus metrics.
+	Registry prometheus.Registerer
+	// FilterEngine, if non-nil, classifies and filters records before they are merged into
+	// flowKeyRecordMap; see the filter package.
+	FilterEngine *filter.Engine
+}
+
+// InitAggregationProcess creates a new AggregationProcess from the given AggregationInput.
+func InitAggregationProcess(input AggregationInput) (*AggregationProcess, error) {
+	if input.MessageChan == nil {
 		return nil, fmt.Errorf("Cannot create AggregationProcess process without message channel.")
-	} else if workerNum <= 0 {
+	} else if input.WorkerNum <= 0 {
 		return nil, fmt.Errorf("Worker number cannot be <= 0.")
 	}
+	flowKeyFunc := input.FlowKeyFunc
+	if flowKeyFunc == nil {
+		flowKeyFunc = FiveTupleFlowKey
+	}
+	var lruList *list.List
+	if input.MaxFlows > 0 {
+		lruList = list.New()
+	}
 	return &AggregationProcess{
-		make(map[FlowKey][]entities.Record),
+		make(map[FlowKey]*flowContext),
 		sync.RWMutex{},
-		messageChan,
-		workerNum,
+		input.MessageChan,
+		input.WorkerNum,
 		make([]*worker, 0),
-		correlateFields,
+		input.CorrelateFields,
+		flowKeyFunc,
+		input.IdleTimeout,
+		input.ActiveTimeout,
+		input.MaxFlows,
+		lruList,
+		input.OnFlowExpired,
+		newAggregationMetrics(input.Registry),
+		input.FilterEngine,
+		make(chan bool),
 		make(chan bool),
 	}, nil
 }
@@ -64,6 +181,9 @@ func (a *AggregationProcess) Start() {
 		w.start()
 		a.workerList = append(a.workerList, w)
 	}
+	if a.idleTimeout > 0 || a.activeTimeout > 0 {
+		go a.expiryCheck()
+	}
 	<-a.stopChan
 }
 
@@ -71,6 +191,9 @@ func (a *AggregationProcess) Stop() {
 	for _, worker := range a.workerList {
 		worker.stop()
 	}
+	if a.idleTimeout > 0 || a.activeTimeout > 0 {
+		a.expiryStopChan <- true
+	}
 	a.stopChan <- true
 }
 
@@ -82,11 +205,23 @@ func (a *AggregationProcess) AggregateMsgByFlowKey(message *entities.Message) er
 	}
 	records := message.Set.GetRecords()
 	for _, record := range records {
-		flowKey, err := getFlowKeyFromRecord(record)
+		if a.filterEngine != nil {
+			keep, err := a.filterEngine.Evaluate(record)
+			if err != nil {
+				// A malformed rule (e.g. a literal whose type does not match the record's field)
+				// must not abort the rest of this message's records; skip just this one.
+				klog.Warningf("Skipping record: %v", err)
+				continue
+			}
+			if !keep {
+				continue
+			}
+		}
+		flowKey, err := a.flowKeyFunc(record)
 		if err != nil {
 			return err
 		}
-		a.correlateRecords(*flowKey, record)
+		a.correlateRecords(flowKey, record)
 	}
 	return nil
 }
@@ -95,10 +230,10 @@ func (a *AggregationProcess) AggregateMsgByFlowKey(message *entities.Message) er
 func (a *AggregationProcess) ForAllRecordsDo(callback FlowKeyRecordMapCallBack) error {
 	a.flowKeyRecordLock.RLock()
 	defer a.flowKeyRecordLock.RUnlock()
-	for k, v := range a.flowKeyRecordMap {
-		err := callback(k, v)
+	for k, ctx := range a.flowKeyRecordMap {
+		err := callback(k, ctx.records)
 		if err != nil {
-			klog.Errorf("Callback execution failed for flow with key: %v, records: %v, error: %v", k, v, err)
+			klog.Errorf("Callback execution failed for flow with key: %v, records: %v, error: %v", k, ctx.records, err)
 			return err
 		}
 	}
@@ -108,14 +243,27 @@ func (a *AggregationProcess) ForAllRecordsDo(callback FlowKeyRecordMapCallBack)
 func (a *AggregationProcess) DeleteFlowKeyFromMap(flowKey FlowKey) {
 	a.flowKeyRecordLock.Lock()
 	defer a.flowKeyRecordLock.Unlock()
+	a.deleteFlowKeyFromMap(flowKey)
+}
+
+// deleteFlowKeyFromMap removes a flow from flowKeyRecordMap and, if LRU tracking is enabled,
+// from lruList. Callers must hold flowKeyRecordLock.
+func (a *AggregationProcess) deleteFlowKeyFromMap(flowKey FlowKey) {
+	if ctx, exist := a.flowKeyRecordMap[flowKey]; exist && ctx.lruElement != nil {
+		a.lruList.Remove(ctx.lruElement)
+	}
 	delete(a.flowKeyRecordMap, flowKey)
 }
 
 // correlateRecords fills records info by correlating incoming and current records
 func (a *AggregationProcess) correlateRecords(flowKey FlowKey, record entities.Record) {
+	start := time.Now()
 	a.flowKeyRecordLock.Lock()
 	defer a.flowKeyRecordLock.Unlock()
-	existingRecords := a.flowKeyRecordMap[flowKey]
+	var existingRecords []entities.Record
+	if ctx, exist := a.flowKeyRecordMap[flowKey]; exist {
+		existingRecords = ctx.records
+	}
 	// only fill the information for record from source node
 	if isRecordFromSrc(record) {
 		var isFilled bool
@@ -145,15 +293,21 @@ func (a *AggregationProcess) correlateRecords(flowKey FlowKey, record entities.R
 	}
 	a.addRecordToMap(flowKey, record)
 	a.removeDuplicates(flowKey)
+	a.touchLRU(flowKey)
+	if a.metrics != nil {
+		a.metrics.flowsAggregatedTotal.Inc()
+		a.metrics.flowMapSize.Set(float64(len(a.flowKeyRecordMap)))
+		a.metrics.aggregationLatency.Observe(time.Since(start).Seconds())
+	}
 }
 
 // removeDuplicates is currently used only in correlateRecords().
 // For other uses, please acquire the flowKeyRecordLock for protection.
 func (a *AggregationProcess) removeDuplicates(flowKey FlowKey) {
-	records := a.flowKeyRecordMap[flowKey]
+	ctx := a.flowKeyRecordMap[flowKey]
 	srcRecords := make([]entities.Record, 0)
 	dstRecords := make([]entities.Record, 0)
-	for _, record := range records {
+	for _, record := range ctx.records {
 		if isRecordFromSrc(record) {
 			srcRecords = append(srcRecords, record)
 		} else {
@@ -161,108 +315,137 @@ func (a *AggregationProcess) removeDuplicates(flowKey FlowKey) {
 		}
 	}
 	if len(srcRecords) != 0 {
-		a.flowKeyRecordMap[flowKey] = srcRecords
+		ctx.records = srcRecords
 	} else {
-		a.flowKeyRecordMap[flowKey] = dstRecords
+		ctx.records = dstRecords
 	}
 }
 
 // addRecordToMap is currently used only in correlateRecords().
 // For other uses, please acquire the flowKeyRecordLock for protection.
 func (a *AggregationProcess) addRecordToMap(flowKey FlowKey, record entities.Record) {
-	if _, exist := a.flowKeyRecordMap[flowKey]; !exist {
-		a.flowKeyRecordMap[flowKey] = make([]entities.Record, 0)
+	start, end := flowTimestamps(record)
+	ctx, exist := a.flowKeyRecordMap[flowKey]
+	if !exist {
+		ctx = &flowContext{records: make([]entities.Record, 0), firstSeen: start, lastSeen: end}
+		a.flowKeyRecordMap[flowKey] = ctx
+	} else {
+		if start.Before(ctx.firstSeen) {
+			ctx.firstSeen = start
+		}
+		if end.After(ctx.lastSeen) {
+			ctx.lastSeen = end
+		}
 	}
-	a.flowKeyRecordMap[flowKey] = append(a.flowKeyRecordMap[flowKey], record)
+	ctx.records = append(ctx.records, record)
 }
 
-func isRecordFromSrc(record entities.Record) bool {
-	ieWithValue, exist := record.GetInfoElementWithValue("sourcePodName")
-	if exist && ieWithValue.Value != "" {
-		return true
+// touchLRU moves flowKey to the front of lruList, evicting the least-recently-updated flow if
+// doing so would exceed maxFlows. It is a no-op when LRU tracking (maxFlows > 0) is disabled.
+// Callers must hold flowKeyRecordLock.
+func (a *AggregationProcess) touchLRU(flowKey FlowKey) {
+	if a.maxFlows <= 0 {
+		return
+	}
+	ctx := a.flowKeyRecordMap[flowKey]
+	if ctx.lruElement != nil {
+		a.lruList.MoveToFront(ctx.lruElement)
+	} else {
+		ctx.lruElement = a.lruList.PushFront(flowKey)
+	}
+	for a.lruList.Len() > a.maxFlows {
+		oldest := a.lruList.Back()
+		oldestKey := oldest.Value.(FlowKey)
+		oldestCtx := a.flowKeyRecordMap[oldestKey]
+		if a.onFlowExpired != nil {
+			a.onFlowExpired(oldestKey, oldestCtx.records, LRUEvictionExpiry)
+		}
+		a.metrics.recordFlowExpired(LRUEvictionExpiry)
+		a.deleteFlowKeyFromMap(oldestKey)
+	}
+	if a.metrics != nil {
+		a.metrics.flowMapSize.Set(float64(len(a.flowKeyRecordMap)))
 	}
-	return false
 }
 
-// getFlowKeyFromRecord returns 5-tuple from data record
-func getFlowKeyFromRecord(record entities.Record) (*FlowKey, error) {
-	flowKey := &FlowKey{}
-	elementList := []string{
-		"sourceTransportPort",
-		"destinationTransportPort",
-		"protocolIdentifier",
-		"sourceIPv4Address",
-		"destinationIPv4Address",
-		"sourceIPv6Address",
-		"destinationIPv6Address",
+// flowTimestamps returns the flow's start and end times, harvested from flowStartSeconds and
+// flowEndSeconds on the record when present, and falling back to wall clock time otherwise.
+func flowTimestamps(record entities.Record) (time.Time, time.Time) {
+	now := time.Now()
+	start, end := now, now
+	if ieWithValue, exist := record.GetInfoElementWithValue("flowStartSeconds"); exist {
+		if t, ok := timeFromIEValue(ieWithValue.Value); ok {
+			start = t
+		}
 	}
-	var isSrcIPv4Filled, isDstIPv4Filled bool
-	for _, name := range elementList {
-		switch name {
-		case "sourceTransportPort", "destinationTransportPort":
-			element, exist := record.GetInfoElementWithValue(name)
-			if !exist {
-				return nil, fmt.Errorf("%s does not exist", name)
-			}
-			port, ok := element.Value.(uint16)
-			if !ok {
-				return nil, fmt.Errorf("%s is not in correct format", name)
-			}
-			if name == "sourceTransportPort" {
-				flowKey.SourcePort = port
-			} else {
-				flowKey.DestinationPort = port
-			}
-		case "sourceIPv4Address", "destinationIPv4Address":
-			element, exist := record.GetInfoElementWithValue(name)
-			if !exist {
-				break
-			}
-			addr, ok := element.Value.(net.IP)
-			if !ok {
-				return nil, fmt.Errorf("%s is not in correct format", name)
-			}
+	if ieWithValue, exist := record.GetInfoElementWithValue("flowEndSeconds"); exist {
+		if t, ok := timeFromIEValue(ieWithValue.Value); ok {
+			end = t
+		}
+	}
+	return start, end
+}
 
-			if strings.Contains(name, "source") {
-				isSrcIPv4Filled = true
-				flowKey.SourceAddress = addr.String()
-			} else {
-				isDstIPv4Filled = true
-				flowKey.DestinationAddress = addr.String()
-			}
-		case "sourceIPv6Address", "destinationIPv6Address":
-			element, exist := record.GetInfoElementWithValue(name)
-			if (isSrcIPv4Filled && strings.Contains(name, "source")) || (isDstIPv4Filled && strings.Contains(name, "destination")) {
-				if exist {
-					klog.Warning("Two ip versions (IPv4 and IPv6) are not supported for flow key.")
-				}
-				break
-			}
-			if !exist {
-				return nil, fmt.Errorf("%s does not exist", name)
-			}
-			addr, ok := element.Value.(net.IP)
-			if !ok {
-				return nil, fmt.Errorf("%s is not in correct format", name)
-			}
-			if strings.Contains(name, "source") {
-				flowKey.SourceAddress = addr.String()
-			} else {
-				flowKey.DestinationAddress = addr.String()
-			}
-		case "protocolIdentifier":
-			element, exist := record.GetInfoElementWithValue(name)
-			if !exist {
-				return nil, fmt.Errorf("%s does not exist", name)
-			}
-			proto, ok := element.Value.(uint8)
-			if !ok {
-				return nil, fmt.Errorf("%s is not in correct format: %v", name, proto)
-			}
-			flowKey.Protocol = proto
+// timeFromIEValue converts the value of a dateTimeSeconds information element, which may be
+// decoded either as a time.Time or as raw epoch seconds, into a time.Time.
+func timeFromIEValue(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case uint32:
+		return time.Unix(int64(v), 0), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// expiryCheck periodically scans flowKeyRecordMap for flows that exceed idleTimeout or
+// activeTimeout and evicts them, invoking onFlowExpired beforehand.
+func (a *AggregationProcess) expiryCheck() {
+	ticker := time.NewTicker(expiryScanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.expiryStopChan:
+			return
+		case <-ticker.C:
+			a.expireFlows()
+		}
+	}
+}
+
+func (a *AggregationProcess) expireFlows() {
+	now := time.Now()
+	a.flowKeyRecordLock.Lock()
+	defer a.flowKeyRecordLock.Unlock()
+	for flowKey, ctx := range a.flowKeyRecordMap {
+		var reason ExpiryReason
+		var expired bool
+		if a.idleTimeout > 0 && now.Sub(ctx.lastSeen) >= a.idleTimeout {
+			reason, expired = IdleTimeoutExpiry, true
+		} else if a.activeTimeout > 0 && now.Sub(ctx.firstSeen) >= a.activeTimeout {
+			reason, expired = ActiveTimeoutExpiry, true
+		}
+		if !expired {
+			continue
+		}
+		if a.onFlowExpired != nil {
+			a.onFlowExpired(flowKey, ctx.records, reason)
 		}
+		a.metrics.recordFlowExpired(reason)
+		a.deleteFlowKeyFromMap(flowKey)
 	}
-	return flowKey, nil
+	if a.metrics != nil {
+		a.metrics.flowMapSize.Set(float64(len(a.flowKeyRecordMap)))
+	}
+}
+
+func isRecordFromSrc(record entities.Record) bool {
+	ieWithValue, exist := record.GetInfoElementWithValue("sourcePodName")
+	if exist && ieWithValue.Value != "" {
+		return true
+	}
+	return false
 }
 
 // addOriginalExporterInfo adds originalExporterIPv4Address and originalObservationDomainId to records in message set
@@ -0,0 +1,129 @@
+package intermediate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/go-ipfix/pkg/entities"
+)
+
+func newTestAggregationProcess(t *testing.T, input AggregationInput) *AggregationProcess {
+	if input.MessageChan == nil {
+		input.MessageChan = make(chan *entities.Message)
+	}
+	if input.WorkerNum == 0 {
+		input.WorkerNum = 1
+	}
+	a, err := InitAggregationProcess(input)
+	if err != nil {
+		t.Fatalf("InitAggregationProcess failed: %v", err)
+	}
+	return a
+}
+
+// TestExpireFlowsIdleTimeout verifies that a flow idle for longer than idleTimeout is expired
+// with IdleTimeoutExpiry and removed from flowKeyRecordMap.
+func TestExpireFlowsIdleTimeout(t *testing.T) {
+	var gotKey FlowKey
+	var gotReason ExpiryReason
+	a := newTestAggregationProcess(t, AggregationInput{
+		IdleTimeout: time.Second,
+		OnFlowExpired: func(key FlowKey, records []entities.Record, reason ExpiryReason) {
+			gotKey, gotReason = key, reason
+		},
+	})
+	key := FlowKey("idle-flow")
+	now := time.Now()
+	a.flowKeyRecordMap[key] = &flowContext{firstSeen: now.Add(-time.Hour), lastSeen: now.Add(-2 * time.Second)}
+
+	a.expireFlows()
+
+	if gotKey != key {
+		t.Errorf("expected OnFlowExpired to be called with key %q, got %q", key, gotKey)
+	}
+	if gotReason != IdleTimeoutExpiry {
+		t.Errorf("expected IdleTimeoutExpiry, got %v", gotReason)
+	}
+	if _, exists := a.flowKeyRecordMap[key]; exists {
+		t.Errorf("expected flow %q to be removed from flowKeyRecordMap", key)
+	}
+}
+
+// TestExpireFlowsActiveTimeout verifies that a flow older than activeTimeout is expired with
+// ActiveTimeoutExpiry even though it is still active (lastSeen is recent).
+func TestExpireFlowsActiveTimeout(t *testing.T) {
+	var gotReason ExpiryReason
+	a := newTestAggregationProcess(t, AggregationInput{
+		ActiveTimeout: time.Second,
+		OnFlowExpired: func(key FlowKey, records []entities.Record, reason ExpiryReason) {
+			gotReason = reason
+		},
+	})
+	key := FlowKey("long-lived-flow")
+	now := time.Now()
+	a.flowKeyRecordMap[key] = &flowContext{firstSeen: now.Add(-time.Hour), lastSeen: now}
+
+	a.expireFlows()
+
+	if gotReason != ActiveTimeoutExpiry {
+		t.Errorf("expected ActiveTimeoutExpiry, got %v", gotReason)
+	}
+	if _, exists := a.flowKeyRecordMap[key]; exists {
+		t.Errorf("expected flow %q to be removed from flowKeyRecordMap", key)
+	}
+}
+
+// TestExpireFlowsNotYetExpired verifies that a recently active flow within both timeouts is left
+// untouched.
+func TestExpireFlowsNotYetExpired(t *testing.T) {
+	called := false
+	a := newTestAggregationProcess(t, AggregationInput{
+		IdleTimeout:   time.Minute,
+		ActiveTimeout: time.Hour,
+		OnFlowExpired: func(key FlowKey, records []entities.Record, reason ExpiryReason) {
+			called = true
+		},
+	})
+	key := FlowKey("fresh-flow")
+	now := time.Now()
+	a.flowKeyRecordMap[key] = &flowContext{firstSeen: now, lastSeen: now}
+
+	a.expireFlows()
+
+	if called {
+		t.Errorf("expected OnFlowExpired not to be called for a fresh flow")
+	}
+	if _, exists := a.flowKeyRecordMap[key]; !exists {
+		t.Errorf("expected flow %q to remain in flowKeyRecordMap", key)
+	}
+}
+
+// TestTouchLRUEvictsOldest verifies that once maxFlows is exceeded, the least-recently-updated
+// flow is evicted with LRUEvictionExpiry.
+func TestTouchLRUEvictsOldest(t *testing.T) {
+	var evicted []FlowKey
+	a := newTestAggregationProcess(t, AggregationInput{
+		MaxFlows: 2,
+		OnFlowExpired: func(key FlowKey, records []entities.Record, reason ExpiryReason) {
+			if reason != LRUEvictionExpiry {
+				t.Errorf("expected LRUEvictionExpiry, got %v", reason)
+			}
+			evicted = append(evicted, key)
+		},
+	})
+	now := time.Now()
+	for _, key := range []FlowKey{"a", "b", "c"} {
+		a.flowKeyRecordMap[key] = &flowContext{firstSeen: now, lastSeen: now}
+		a.touchLRU(key)
+	}
+
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Errorf("expected flow %q to be evicted first, got %v", "a", evicted)
+	}
+	if _, exists := a.flowKeyRecordMap["a"]; exists {
+		t.Errorf("expected flow %q to be removed from flowKeyRecordMap", "a")
+	}
+	if len(a.flowKeyRecordMap) != 2 {
+		t.Errorf("expected flowKeyRecordMap to have 2 entries, got %d", len(a.flowKeyRecordMap))
+	}
+}
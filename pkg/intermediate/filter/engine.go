@@ -0,0 +1,152 @@
+package filter
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"k8s.io/klog"
+
+	"github.com/vmware/go-ipfix/pkg/entities"
+)
+
+// Engine evaluates an ordered list of compiled Rules against records in O(len(rules)) per
+// record, routing matched records to named output channels so downstream consumers can subscribe
+// to classified subsets instead of post-filtering via AggregationProcess.ForAllRecordsDo.
+type Engine struct {
+	mu       sync.RWMutex
+	rules    []*Rule
+	channels map[string]chan entities.Record
+	// rulesPath is the YAML file ReloadRules re-reads from; empty if the engine was not created
+	// via NewEngineFromFile.
+	rulesPath string
+}
+
+// NewEngine creates an Engine with no rules and no rules file. Use SetRules to populate it.
+func NewEngine() *Engine {
+	return &Engine{channels: make(map[string]chan entities.Record)}
+}
+
+// NewEngineFromFile creates an Engine and loads its initial rules from a YAML file at path.
+// Subsequent calls to ReloadRules re-read rules from the same path.
+func NewEngineFromFile(path string) (*Engine, error) {
+	e := NewEngine()
+	e.rulesPath = path
+	if err := e.ReloadRules(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// SetRules atomically replaces the engine's rule set.
+func (e *Engine) SetRules(rules []*Rule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+}
+
+// ReloadRules re-reads rules from the YAML file the engine was created with and atomically swaps
+// them in. It returns an error, leaving the current rules in place, if the engine was created
+// with NewEngine (no rules file) or if the file cannot be read or parsed.
+func (e *Engine) ReloadRules() error {
+	if e.rulesPath == "" {
+		return fmt.Errorf("engine has no rules file to reload from")
+	}
+	rules, err := LoadRulesFromFile(e.rulesPath)
+	if err != nil {
+		return err
+	}
+	e.SetRules(rules)
+	return nil
+}
+
+// WatchSIGHUP spawns a goroutine that calls ReloadRules whenever the process receives SIGHUP,
+// logging the outcome. The goroutine exits when stopChan is closed.
+func (e *Engine) WatchSIGHUP(stopChan <-chan struct{}) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(sigChan)
+		for {
+			select {
+			case <-stopChan:
+				return
+			case <-sigChan:
+				if err := e.ReloadRules(); err != nil {
+					klog.Errorf("Failed to reload rules from %s: %v", e.rulesPath, err)
+				} else {
+					klog.Infof("Reloaded rules from %s", e.rulesPath)
+				}
+			}
+		}
+	}()
+}
+
+// Channel returns the named output channel that Route actions publish to, creating it with a
+// buffer of channelBufferSize records if it does not already exist.
+func (e *Engine) Channel(name string) chan entities.Record {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ch, exist := e.channels[name]
+	if !exist {
+		ch = make(chan entities.Record, channelBufferSize)
+		e.channels[name] = ch
+	}
+	return ch
+}
+
+// channelBufferSize bounds each Route channel so a slow or absent subscriber cannot block
+// Evaluate; records are dropped with a warning if the buffer is full.
+const channelBufferSize = 256
+
+// Evaluate runs record through the engine's rules in order and returns whether the record should
+// continue into normal aggregation. The first matching Accept or Drop rule stops evaluation with
+// that outcome; a matching Route rule stops evaluation, publishes the record to its channel, and
+// excludes it from normal aggregation; a matching Tag rule mutates the record and evaluation
+// continues to the next rule. If no rule matches, the record is accepted.
+func (e *Engine) Evaluate(record entities.Record) (bool, error) {
+	e.mu.RLock()
+	rules := e.rules
+	e.mu.RUnlock()
+
+	for _, rule := range rules {
+		matched, err := rule.Predicate(record)
+		if err != nil {
+			return false, fmt.Errorf("rule %q: %v", rule.Name, err)
+		}
+		if !matched {
+			continue
+		}
+		switch rule.Action.Kind {
+		case Accept:
+			return true, nil
+		case Drop:
+			return false, nil
+		case Tag:
+			tagRecord(record, rule.Action.TagName, rule.Action.TagValue)
+		case Route:
+			ch := e.Channel(rule.Action.Channel)
+			select {
+			case ch <- record:
+			default:
+				klog.Warningf("Route channel %q is full, dropping record", rule.Action.Channel)
+			}
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// tagRecord sets ieName's value on record. If record does not carry ieName, tagging is a no-op: a
+// record lacking the tagged field is not an error condition, and must not abort evaluation of the
+// rest of the batch.
+func tagRecord(record entities.Record, ieName string, value interface{}) {
+	ieWithValue, exist := record.GetInfoElementWithValue(ieName)
+	if !exist {
+		klog.Warningf("Cannot tag: information element %q does not exist on record", ieName)
+		return
+	}
+	ieWithValue.Value = value
+}
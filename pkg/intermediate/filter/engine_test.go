@@ -0,0 +1,70 @@
+package filter
+
+import (
+	"net"
+	"testing"
+)
+
+// TestEvaluateTagMissingIEDoesNotAbort guards against a Tag rule aborting evaluation of an entire
+// batch just because one record does not carry the tagged information element; tagging must be a
+// no-op for that record, and the engine must still fall through to the implicit accept.
+func TestEvaluateTagMissingIEDoesNotAbort(t *testing.T) {
+	rule, err := CompileRule("tag-missing", `protocolIdentifier == 6`, TagAction("sourcePodNamespace", "prod"))
+	if err != nil {
+		t.Fatalf("CompileRule failed: %v", err)
+	}
+	engine := NewEngine()
+	engine.SetRules([]*Rule{rule})
+
+	record := newTestRecord(map[string]interface{}{
+		"protocolIdentifier": uint8(6), // no sourcePodNamespace on this record
+	})
+	keep, err := engine.Evaluate(record)
+	if err != nil {
+		t.Fatalf("expected Tag rule on a missing IE to not error, got: %v", err)
+	}
+	if !keep {
+		t.Errorf("expected record to be kept (implicit accept) after a Tag rule")
+	}
+}
+
+// TestEvaluateTypeMismatchErrors documents that Evaluate does still return an error when a rule
+// itself is malformed (here, a literal compared against a field of an incompatible type) rather
+// than silently treating it as a non-match. Callers such as
+// AggregationProcess.AggregateMsgByFlowKey must skip just the offending record on this error, not
+// abort the rest of the batch.
+func TestEvaluateTypeMismatchErrors(t *testing.T) {
+	rule, err := CompileRule("bad-literal", `sourceIPv4Address == 6`, DropAction())
+	if err != nil {
+		t.Fatalf("CompileRule failed: %v", err)
+	}
+	engine := NewEngine()
+	engine.SetRules([]*Rule{rule})
+
+	record := newTestRecord(map[string]interface{}{
+		"sourceIPv4Address": net.ParseIP("1.1.1.1"),
+	})
+	if _, err := engine.Evaluate(record); err == nil {
+		t.Errorf("expected a type-mismatch comparison to return an error")
+	}
+}
+
+func TestEvaluateDrop(t *testing.T) {
+	rule, err := CompileRule("drop-icmp", `protocolIdentifier == 1`, DropAction())
+	if err != nil {
+		t.Fatalf("CompileRule failed: %v", err)
+	}
+	engine := NewEngine()
+	engine.SetRules([]*Rule{rule})
+
+	record := newTestRecord(map[string]interface{}{
+		"protocolIdentifier": uint8(1),
+	})
+	keep, err := engine.Evaluate(record)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if keep {
+		t.Errorf("expected record matching a Drop rule to not be kept")
+	}
+}
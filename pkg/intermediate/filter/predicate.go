@@ -0,0 +1,239 @@
+package filter
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vmware/go-ipfix/pkg/entities"
+)
+
+// inClausePattern matches `<ident> in {a, b, c}`, the one piece of the DSL that is not valid Go
+// expression syntax. CompilePredicate rewrites it into an OR of equalities before parsing.
+var inClausePattern = regexp.MustCompile(`(\w+)\s+in\s+\{([^}]*)\}`)
+
+// CompilePredicate compiles a small expression DSL over information element names into a
+// Predicate. Supported syntax: identifiers refer to IE names on the record (e.g.
+// protocolIdentifier); ==, !=, <, <=, >, >= compare an IE against an int or quoted string
+// literal; && and || combine sub-expressions; parentheses group them; and `<ident> in {a, b, c}`
+// tests membership in a literal set, e.g.:
+//
+//	protocolIdentifier == 6 && destinationTransportPort in {80, 443} && sourcePodNamespace == "prod"
+//
+// Not every record carries every information element (e.g. ICMP records have no ports; flows
+// outside a namespaced entity have no sourcePodNamespace). A comparison against a missing IE
+// simply evaluates to false rather than erroring, so one record's missing field cannot abort
+// evaluation of the rest of the batch.
+func CompilePredicate(expr string) (Predicate, error) {
+	rewritten := rewriteInClauses(expr)
+	astExpr, err := parser.ParseExpr(rewritten)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %v", expr, err)
+	}
+	return func(record entities.Record) (bool, error) {
+		value, err := evalExpr(astExpr, record)
+		if err != nil {
+			return false, err
+		}
+		result, ok := value.(bool)
+		if !ok {
+			return false, fmt.Errorf("expression %q does not evaluate to a boolean", expr)
+		}
+		return result, nil
+	}, nil
+}
+
+func rewriteInClauses(expr string) string {
+	return inClausePattern.ReplaceAllStringFunc(expr, func(clause string) string {
+		parts := inClausePattern.FindStringSubmatch(clause)
+		ident, set := parts[1], parts[2]
+		var terms []string
+		for _, item := range strings.Split(set, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			terms = append(terms, fmt.Sprintf("%s == %s", ident, item))
+		}
+		return "(" + strings.Join(terms, " || ") + ")"
+	})
+}
+
+// missingValue stands in for an identifier that names an information element absent from the
+// record being evaluated. compare() returns false for every operator, != included, when either
+// operand is a missingValue, so a comparison against a missing IE evaluates to false instead of
+// erroring.
+type missingValue struct{}
+
+func evalExpr(node ast.Expr, record entities.Record) (interface{}, error) {
+	switch n := node.(type) {
+	case *ast.ParenExpr:
+		return evalExpr(n.X, record)
+	case *ast.Ident:
+		element, exist := record.GetInfoElementWithValue(n.Name)
+		if !exist {
+			return missingValue{}, nil
+		}
+		return element.Value, nil
+	case *ast.BasicLit:
+		switch n.Kind {
+		case token.INT:
+			v, err := strconv.ParseInt(n.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid integer literal %q: %v", n.Value, err)
+			}
+			return v, nil
+		case token.STRING:
+			v, err := strconv.Unquote(n.Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string literal %q: %v", n.Value, err)
+			}
+			return v, nil
+		default:
+			return nil, fmt.Errorf("unsupported literal %q", n.Value)
+		}
+	case *ast.BinaryExpr:
+		return evalBinaryExpr(n, record)
+	default:
+		return nil, fmt.Errorf("unsupported expression syntax: %T", node)
+	}
+}
+
+func evalBinaryExpr(n *ast.BinaryExpr, record entities.Record) (interface{}, error) {
+	switch n.Op {
+	case token.LAND, token.LOR:
+		left, err := evalBoolOperand(n.X, record)
+		if err != nil {
+			return nil, err
+		}
+		if n.Op == token.LAND && !left {
+			return false, nil
+		}
+		if n.Op == token.LOR && left {
+			return true, nil
+		}
+		return evalBoolOperand(n.Y, record)
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		left, err := evalExpr(n.X, record)
+		if err != nil {
+			return nil, err
+		}
+		right, err := evalExpr(n.Y, record)
+		if err != nil {
+			return nil, err
+		}
+		return compare(n.Op, left, right)
+	default:
+		return nil, fmt.Errorf("unsupported operator %q", n.Op)
+	}
+}
+
+func evalBoolOperand(node ast.Expr, record entities.Record) (bool, error) {
+	value, err := evalExpr(node, record)
+	if err != nil {
+		return false, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("operand of && / || does not evaluate to a boolean")
+	}
+	return b, nil
+}
+
+// compare evaluates op between the value extracted from a record and a literal from the
+// expression, coercing both to a common representation (int64, string, or IP address string).
+func compare(op token.Token, left, right interface{}) (bool, error) {
+	if _, ok := left.(missingValue); ok {
+		return false, nil
+	}
+	if _, ok := right.(missingValue); ok {
+		return false, nil
+	}
+
+	leftInt, leftIsInt := asInt64(left)
+	rightInt, rightIsInt := asInt64(right)
+	if leftIsInt && rightIsInt {
+		return compareInt64(op, leftInt, rightInt)
+	}
+
+	leftStr, leftIsStr := asString(left)
+	rightStr, rightIsStr := asString(right)
+	if leftIsStr && rightIsStr {
+		return compareString(op, leftStr, rightStr)
+	}
+
+	return false, fmt.Errorf("cannot compare %v (%T) and %v (%T)", left, left, right, right)
+}
+
+func compareInt64(op token.Token, left, right int64) (bool, error) {
+	switch op {
+	case token.EQL:
+		return left == right, nil
+	case token.NEQ:
+		return left != right, nil
+	case token.LSS:
+		return left < right, nil
+	case token.LEQ:
+		return left <= right, nil
+	case token.GTR:
+		return left > right, nil
+	case token.GEQ:
+		return left >= right, nil
+	default:
+		return false, fmt.Errorf("unsupported operator %q", op)
+	}
+}
+
+func compareString(op token.Token, left, right string) (bool, error) {
+	switch op {
+	case token.EQL:
+		return left == right, nil
+	case token.NEQ:
+		return left != right, nil
+	case token.LSS:
+		return left < right, nil
+	case token.LEQ:
+		return left <= right, nil
+	case token.GTR:
+		return left > right, nil
+	case token.GEQ:
+		return left >= right, nil
+	default:
+		return false, fmt.Errorf("unsupported operator %q", op)
+	}
+}
+
+func asInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	case int:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func asString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case net.IP:
+		return v.String(), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,54 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/vmware/go-ipfix/pkg/entities"
+)
+
+func newTestRecord(fields map[string]interface{}) entities.Record {
+	record := entities.NewDataRecord(0)
+	for name, value := range fields {
+		record.AddInfoElement(&entities.InfoElement{Name: name}, value)
+	}
+	return record
+}
+
+// TestCompilePredicateMissingIE guards against a predicate referencing an information element
+// that is absent from the record (e.g. sourceTransportPort on an ICMP record): it must evaluate
+// to false, not return an error, so that one record's missing field cannot abort evaluation of
+// the rest of the batch.
+func TestCompilePredicateMissingIE(t *testing.T) {
+	predicate, err := CompilePredicate(`destinationTransportPort == 443`)
+	if err != nil {
+		t.Fatalf("CompilePredicate failed: %v", err)
+	}
+	record := newTestRecord(map[string]interface{}{
+		"protocolIdentifier": uint8(1), // ICMP; no ports on the record
+	})
+	matched, err := predicate(record)
+	if err != nil {
+		t.Fatalf("expected no error for a missing information element, got: %v", err)
+	}
+	if matched {
+		t.Errorf("expected predicate to not match when the compared IE is missing")
+	}
+}
+
+func TestCompilePredicateMatches(t *testing.T) {
+	predicate, err := CompilePredicate(`protocolIdentifier == 6 && destinationTransportPort in {80, 443}`)
+	if err != nil {
+		t.Fatalf("CompilePredicate failed: %v", err)
+	}
+	record := newTestRecord(map[string]interface{}{
+		"protocolIdentifier":       uint8(6),
+		"destinationTransportPort": uint16(443),
+	})
+	matched, err := predicate(record)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !matched {
+		t.Errorf("expected predicate to match")
+	}
+}
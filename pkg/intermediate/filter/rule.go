@@ -0,0 +1,74 @@
+// Package filter implements a small rule engine for classifying and filtering IPFIX records
+// before they are merged into an AggregationProcess's flowKeyRecordMap. Rules are expressed as a
+// predicate over information element names (see CompilePredicate) paired with an Action.
+package filter
+
+import (
+	"fmt"
+
+	"github.com/vmware/go-ipfix/pkg/entities"
+)
+
+// ActionKind identifies what an Action does with a record that matches a Rule's predicate.
+type ActionKind int
+
+const (
+	// Accept keeps the record in the normal aggregation path and stops evaluating further rules.
+	Accept ActionKind = iota
+	// Drop excludes the record from aggregation and stops evaluating further rules.
+	Drop
+	// Tag overwrites the value of an information element already on the record (a no-op if the
+	// record does not carry it), then evaluation continues to the next rule.
+	Tag
+	// Route publishes the record to a named output channel (see Engine.Channel) instead of the
+	// default aggregation path, and stops evaluating further rules.
+	Route
+)
+
+// Action is the effect applied to a record when a Rule's predicate matches.
+type Action struct {
+	Kind ActionKind
+	// TagName and TagValue are used when Kind == Tag.
+	TagName  string
+	TagValue interface{}
+	// Channel is used when Kind == Route.
+	Channel string
+}
+
+// AcceptAction returns an Action that keeps the record in the default aggregation path.
+func AcceptAction() Action { return Action{Kind: Accept} }
+
+// DropAction returns an Action that excludes the record from aggregation.
+func DropAction() Action { return Action{Kind: Drop} }
+
+// TagAction returns an Action that sets information element ieName to value on the record.
+func TagAction(ieName string, value interface{}) Action {
+	return Action{Kind: Tag, TagName: ieName, TagValue: value}
+}
+
+// RouteAction returns an Action that publishes the record to the named output channel.
+func RouteAction(channel string) Action {
+	return Action{Kind: Route, Channel: channel}
+}
+
+// Predicate is a compiled boolean expression over an entities.Record, produced by CompilePredicate.
+type Predicate func(record entities.Record) (bool, error)
+
+// Rule pairs a compiled Predicate with the Action to take when it matches. Rules are evaluated
+// in order by an Engine; the first matching Accept, Drop or Route rule determines the outcome,
+// while a matching Tag rule lets evaluation continue.
+type Rule struct {
+	Name      string
+	Predicate Predicate
+	Action    Action
+}
+
+// CompileRule compiles expr into a Rule with the given name and action. expr uses the small
+// expression DSL documented on CompilePredicate.
+func CompileRule(name, expr string, action Action) (*Rule, error) {
+	predicate, err := CompilePredicate(expr)
+	if err != nil {
+		return nil, fmt.Errorf("rule %q: %v", name, err)
+	}
+	return &Rule{Name: name, Predicate: predicate, Action: action}, nil
+}
@@ -0,0 +1,84 @@
+package filter
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ruleConfig is the YAML representation of a single Rule.
+type ruleConfig struct {
+	Name   string       `yaml:"name"`
+	Expr   string       `yaml:"expr"`
+	Action actionConfig `yaml:"action"`
+}
+
+type actionConfig struct {
+	// Type is one of "accept", "drop", "tag", "route".
+	Type     string      `yaml:"type"`
+	TagName  string      `yaml:"tagName,omitempty"`
+	TagValue interface{} `yaml:"tagValue,omitempty"`
+	Channel  string      `yaml:"channel,omitempty"`
+}
+
+// LoadRulesFromYAML parses an ordered list of rules from YAML in the following form:
+//
+//	- name: route-suspicious-east-west
+//	  expr: protocolIdentifier == 6 && destinationTransportPort in {80, 443}
+//	  action:
+//	    type: route
+//	    channel: suspicious-east-west
+//	- name: default-accept
+//	  expr: protocolIdentifier == 6
+//	  action:
+//	    type: accept
+func LoadRulesFromYAML(data []byte) ([]*Rule, error) {
+	var configs []ruleConfig
+	if err := yaml.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse rules YAML: %v", err)
+	}
+	rules := make([]*Rule, 0, len(configs))
+	for _, config := range configs {
+		action, err := toAction(config.Action)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %v", config.Name, err)
+		}
+		rule, err := CompileRule(config.Name, config.Expr, action)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// LoadRulesFromFile reads and parses an ordered list of rules from the YAML file at path.
+func LoadRulesFromFile(path string) ([]*Rule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file %s: %v", path, err)
+	}
+	return LoadRulesFromYAML(data)
+}
+
+func toAction(config actionConfig) (Action, error) {
+	switch config.Type {
+	case "accept":
+		return AcceptAction(), nil
+	case "drop":
+		return DropAction(), nil
+	case "tag":
+		if config.TagName == "" {
+			return Action{}, fmt.Errorf("tag action requires tagName")
+		}
+		return TagAction(config.TagName, config.TagValue), nil
+	case "route":
+		if config.Channel == "" {
+			return Action{}, fmt.Errorf("route action requires channel")
+		}
+		return RouteAction(config.Channel), nil
+	default:
+		return Action{}, fmt.Errorf("unknown action type %q", config.Type)
+	}
+}
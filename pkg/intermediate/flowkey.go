@@ -0,0 +1,216 @@
+package intermediate
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"k8s.io/klog"
+
+	"github.com/vmware/go-ipfix/pkg/entities"
+)
+
+// flowKeyFieldSeparator joins the individual fields making up a FlowKey. It is not interpreted
+// by callers; it only needs to avoid colliding with the string form of the fields it separates.
+const flowKeyFieldSeparator = "|"
+
+// FiveTupleFlowKey is the default FlowKeyFunc. It reproduces the previous hard-coded behavior of
+// keying on source/destination address, source/destination transport port, and protocol.
+func FiveTupleFlowKey(record entities.Record) (FlowKey, error) {
+	srcAddr, dstAddr, err := getAddresses(record)
+	if err != nil {
+		return "", err
+	}
+	srcPort, dstPort, err := getTransportPorts(record)
+	if err != nil {
+		return "", err
+	}
+	proto, err := getProtocolIdentifier(record)
+	if err != nil {
+		return "", err
+	}
+	return joinFlowKeyFields(srcAddr, dstAddr, srcPort, dstPort, proto), nil
+}
+
+// FiveTupleVLANDSCPFlowKey extends FiveTupleFlowKey with the VLAN id and DSCP value, so that
+// traffic sharing a 5-tuple (e.g. across VLANs on a trunked interface, or with differing QoS
+// markings) is aggregated separately.
+func FiveTupleVLANDSCPFlowKey(record entities.Record) (FlowKey, error) {
+	fiveTuple, err := FiveTupleFlowKey(record)
+	if err != nil {
+		return "", err
+	}
+	vlanID, err := getUint16Field(record, "vlanId")
+	if err != nil {
+		return "", err
+	}
+	dscp, err := getUint8Field(record, "ipClassOfService")
+	if err != nil {
+		return "", err
+	}
+	return joinFlowKeyFields(string(fiveTuple), vlanID, dscp), nil
+}
+
+// IngressInterfaceSourcePrefixFlowKey aggregates on the ingress interface and the /24 (IPv4) or
+// /64 (IPv6) prefix containing the source address, for per-interface or per-subnet granularity.
+func IngressInterfaceSourcePrefixFlowKey(record entities.Record) (FlowKey, error) {
+	ingressInterface, err := getUint32Field(record, "ingressInterface")
+	if err != nil {
+		return "", err
+	}
+	srcAddr, _, err := getAddresses(record)
+	if err != nil {
+		return "", err
+	}
+	prefix, err := sourcePrefix(srcAddr)
+	if err != nil {
+		return "", err
+	}
+	return joinFlowKeyFields(ingressInterface, prefix), nil
+}
+
+// SubscriberFlowKey aggregates on the originating observation domain and flow direction, so that
+// all flows reported by a given exporter for a given direction (e.g. ingress vs egress for a
+// subscriber) are grouped together regardless of 5-tuple.
+func SubscriberFlowKey(record entities.Record) (FlowKey, error) {
+	element, exist := record.GetInfoElementWithValue("observationDomainId")
+	if !exist {
+		return "", fmt.Errorf("observationDomainId does not exist")
+	}
+	obsDomainID, ok := element.Value.(uint32)
+	if !ok {
+		return "", fmt.Errorf("observationDomainId is not in correct format")
+	}
+	flowDirection, err := getUint8Field(record, "flowDirection")
+	if err != nil {
+		return "", err
+	}
+	return joinFlowKeyFields(obsDomainID, flowDirection), nil
+}
+
+func joinFlowKeyFields(fields ...interface{}) FlowKey {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = fmt.Sprintf("%v", field)
+	}
+	return FlowKey(strings.Join(parts, flowKeyFieldSeparator))
+}
+
+func getAddresses(record entities.Record) (string, string, error) {
+	elementList := []string{
+		"sourceIPv4Address",
+		"destinationIPv4Address",
+		"sourceIPv6Address",
+		"destinationIPv6Address",
+	}
+	var srcAddr, dstAddr string
+	var isSrcIPv4Filled, isDstIPv4Filled bool
+	for _, name := range elementList {
+		switch name {
+		case "sourceIPv4Address", "destinationIPv4Address":
+			element, exist := record.GetInfoElementWithValue(name)
+			if !exist {
+				continue
+			}
+			addr, ok := element.Value.(net.IP)
+			if !ok {
+				return "", "", fmt.Errorf("%s is not in correct format", name)
+			}
+			if strings.Contains(name, "source") {
+				isSrcIPv4Filled = true
+				srcAddr = addr.String()
+			} else {
+				isDstIPv4Filled = true
+				dstAddr = addr.String()
+			}
+		case "sourceIPv6Address", "destinationIPv6Address":
+			element, exist := record.GetInfoElementWithValue(name)
+			if (isSrcIPv4Filled && strings.Contains(name, "source")) || (isDstIPv4Filled && strings.Contains(name, "destination")) {
+				if exist {
+					klog.Warning("Two ip versions (IPv4 and IPv6) are not supported for flow key.")
+				}
+				continue
+			}
+			if !exist {
+				return "", "", fmt.Errorf("%s does not exist", name)
+			}
+			addr, ok := element.Value.(net.IP)
+			if !ok {
+				return "", "", fmt.Errorf("%s is not in correct format", name)
+			}
+			if strings.Contains(name, "source") {
+				srcAddr = addr.String()
+			} else {
+				dstAddr = addr.String()
+			}
+		}
+	}
+	return srcAddr, dstAddr, nil
+}
+
+func getTransportPorts(record entities.Record) (uint16, uint16, error) {
+	srcPort, err := getUint16Field(record, "sourceTransportPort")
+	if err != nil {
+		return 0, 0, err
+	}
+	dstPort, err := getUint16Field(record, "destinationTransportPort")
+	if err != nil {
+		return 0, 0, err
+	}
+	return srcPort, dstPort, nil
+}
+
+func getProtocolIdentifier(record entities.Record) (uint8, error) {
+	return getUint8Field(record, "protocolIdentifier")
+}
+
+func getUint8Field(record entities.Record, name string) (uint8, error) {
+	element, exist := record.GetInfoElementWithValue(name)
+	if !exist {
+		return 0, fmt.Errorf("%s does not exist", name)
+	}
+	value, ok := element.Value.(uint8)
+	if !ok {
+		return 0, fmt.Errorf("%s is not in correct format", name)
+	}
+	return value, nil
+}
+
+func getUint16Field(record entities.Record, name string) (uint16, error) {
+	element, exist := record.GetInfoElementWithValue(name)
+	if !exist {
+		return 0, fmt.Errorf("%s does not exist", name)
+	}
+	value, ok := element.Value.(uint16)
+	if !ok {
+		return 0, fmt.Errorf("%s is not in correct format", name)
+	}
+	return value, nil
+}
+
+func getUint32Field(record entities.Record, name string) (uint32, error) {
+	element, exist := record.GetInfoElementWithValue(name)
+	if !exist {
+		return 0, fmt.Errorf("%s does not exist", name)
+	}
+	value, ok := element.Value.(uint32)
+	if !ok {
+		return 0, fmt.Errorf("%s is not in correct format", name)
+	}
+	return value, nil
+}
+
+// sourcePrefix truncates an address string to its containing /24 (IPv4) or /64 (IPv6) prefix.
+func sourcePrefix(addr string) (string, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", fmt.Errorf("%s is not a valid IP address", addr)
+	}
+	var mask net.IPMask
+	if ip.To4() != nil {
+		mask = net.CIDRMask(24, 32)
+	} else {
+		mask = net.CIDRMask(64, 128)
+	}
+	return ip.Mask(mask).String(), nil
+}
@@ -0,0 +1,33 @@
+package intermediate
+
+import (
+	"net"
+	"testing"
+
+	"github.com/vmware/go-ipfix/pkg/entities"
+)
+
+func newTestRecord(t *testing.T, fields map[string]interface{}) entities.Record {
+	record := entities.NewDataRecord(0)
+	for name, value := range fields {
+		record.AddInfoElement(&entities.InfoElement{Name: name}, value)
+	}
+	return record
+}
+
+// TestFiveTupleVLANDSCPFlowKey guards against regressing vlanId's type: it is unsigned16 per the
+// IPFIX registry, not unsigned32, so a record carrying a uint16 value must not error out.
+func TestFiveTupleVLANDSCPFlowKey(t *testing.T) {
+	record := newTestRecord(t, map[string]interface{}{
+		"sourceIPv4Address":        net.ParseIP("1.1.1.1"),
+		"destinationIPv4Address":   net.ParseIP("2.2.2.2"),
+		"sourceTransportPort":      uint16(1234),
+		"destinationTransportPort": uint16(80),
+		"protocolIdentifier":       uint8(6),
+		"vlanId":                   uint16(100),
+		"ipClassOfService":         uint8(0),
+	})
+	if _, err := FiveTupleVLANDSCPFlowKey(record); err != nil {
+		t.Fatalf("FiveTupleVLANDSCPFlowKey returned unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package intermediate
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "ipfix"
+	metricsSubsystem = "aggregation"
+)
+
+// aggregationMetrics holds the Prometheus collectors exported by an AggregationProcess. It is
+// nil when AggregationInput.Registry is nil, in which case all instrumentation is skipped.
+type aggregationMetrics struct {
+	flowsAggregatedTotal prometheus.Counter
+	flowsExpiredTotal    *prometheus.CounterVec
+	flowMapSize          prometheus.Gauge
+	// aggregationLatency is a Summary, not a Histogram, so that streaming p50/p90/p99 quantiles
+	// are tracked (via the beorn7/perks/quantile algorithm client_golang uses internally) without
+	// retaining a full latency distribution in memory.
+	aggregationLatency prometheus.Summary
+}
+
+// newAggregationMetrics creates and registers the metrics for an AggregationProcess on
+// registerer. It returns nil, disabling instrumentation, if registerer is nil.
+func newAggregationMetrics(registerer prometheus.Registerer) *aggregationMetrics {
+	if registerer == nil {
+		return nil
+	}
+	m := &aggregationMetrics{
+		flowsAggregatedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "flows_aggregated_total",
+			Help:      "Number of records merged into flowKeyRecordMap.",
+		}),
+		flowsExpiredTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "flows_expired_total",
+			Help:      "Number of flows removed from flowKeyRecordMap, by expiry reason.",
+		}, []string{"reason"}),
+		flowMapSize: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "flow_map_size",
+			Help:      "Current number of flows tracked in flowKeyRecordMap.",
+		}),
+		aggregationLatency: prometheus.NewSummary(prometheus.SummaryOpts{
+			Namespace:  metricsNamespace,
+			Subsystem:  metricsSubsystem,
+			Name:       "record_aggregation_latency_seconds",
+			Help:       "Latency of merging a single record into flowKeyRecordMap.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		}),
+	}
+	registerer.MustRegister(m.flowsAggregatedTotal, m.flowsExpiredTotal, m.flowMapSize, m.aggregationLatency)
+	return m
+}
+
+// recordFlowExpired increments flowsExpiredTotal for reason. It is a no-op if metrics are disabled.
+func (m *aggregationMetrics) recordFlowExpired(reason ExpiryReason) {
+	if m == nil {
+		return
+	}
+	m.flowsExpiredTotal.WithLabelValues(reason.String()).Inc()
+}